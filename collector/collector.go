@@ -0,0 +1,20 @@
+/*
+ * hpraidmon/collector - pluggable ingestion of RAID controller status
+ *
+ * A Collector produces the current controller/array/drive tree from
+ * somewhere: pre-captured text (stdin or a file) or by invoking one of
+ * the vendor command-line tools directly (hpacucli or ssacli). storcli64
+ * is not supported: --source=storcli is rejected with an error.
+ * main() picks one via --source and everything downstream (filtering,
+ * JSON/Prometheus rendering, daemon diffing) only ever sees
+ * []*Controller, never what produced it.
+ */
+package collector
+
+import "context"
+
+// Collector produces a snapshot of all RAID controllers known to the
+// host.
+type Collector interface {
+	Collect(ctx context.Context) ([]*Controller, error)
+}
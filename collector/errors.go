@@ -0,0 +1,16 @@
+package collector
+
+import "fmt"
+
+// ParseError describes a single line of hpacucli/ssacli
+// output that could not be parsed, together with the line number it
+// came from so operators can go find it in the original dump.
+type ParseError struct {
+	Line    int
+	Content string
+	Reason  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s: %q", e.Line, e.Reason, e.Content)
+}
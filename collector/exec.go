@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// execCollector runs a vendor RAID management binary and parses its
+// standard output the same way as a pre-captured dump.
+type execCollector struct {
+	path   string
+	args   []string
+	strict bool
+}
+
+func (e execCollector) Collect(ctx context.Context) ([]*Controller, error) {
+	cmd := exec.CommandContext(ctx, e.path, e.args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running %s: %w", e.path, err)
+	}
+	return ParseControllers(out, e.strict, os.Stderr)
+}
+
+// execBinaries maps a --source name to the binary it invokes and the
+// arguments that make it print the full controller/array/drive tree.
+// hpacucli and ssacli share the same "ctrl all show config detail"
+// grammar that ParseControllers understands. storcli64 is deliberately
+// not listed here: its "/call show all" output is a different
+// MegaRAID-style table, not the array/physicaldrive grammar in
+// collector/model.go, so running it through ParseControllers would
+// just fail (or silently return nothing in non-strict mode).
+var execBinaries = []struct {
+	source string
+	binary string
+	args   []string
+}{
+	{"hpacucli", "hpacucli", []string{"ctrl", "all", "show", "config", "detail"}},
+	{"ssacli", "ssacli", []string{"ctrl", "all", "show", "config", "detail"}},
+}
+
+// NewExecCollector looks up the binary for the given --source, falling
+// back to the others in execBinaries (in order) if it is not found in
+// PATH. This lets a single --source=hpacucli config work unmodified on
+// hosts that only ship the newer ssacli tool.
+func NewExecCollector(source string, strict bool) (Collector, error) {
+	if source == "storcli" {
+		return nil, fmt.Errorf("--source=storcli is not supported yet: storcli64's output format is not compatible with this parser")
+	}
+
+	start := -1
+	for i, b := range execBinaries {
+		if b.source == source {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return nil, fmt.Errorf("unknown exec source %q", source)
+	}
+
+	var missing []string
+	for i := 0; i < len(execBinaries); i++ {
+		b := execBinaries[(start+i)%len(execBinaries)]
+		if path, err := exec.LookPath(b.binary); err == nil {
+			return execCollector{path: path, args: b.args, strict: strict}, nil
+		}
+		missing = append(missing, b.binary)
+	}
+
+	return nil, fmt.Errorf("none of the RAID management binaries were found in PATH: %v", missing)
+}
@@ -0,0 +1,284 @@
+/*
+ * hpraidmon v0.1.0 - Monitor status of HP RAID controllers by parsing output of hpacucli
+ * Copyright (C) 2014 gdm85 - https://github.com/gdm85/hpraidmon/
+
+This program is free software; you can redistribute it and/or
+modify it under the terms of the GNU General Public License
+as published by the Free Software Foundation; either version 2
+of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program; if not, write to the Free Software
+Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package collector
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type StorageEnclosureProcessor struct {
+	VendorId string
+	Model    string
+	Expander uint
+	WWID     string
+}
+
+type Controller struct {
+	Name         string
+	Type         string
+	Slot         uint
+	SerialNumber string
+	SEP          StorageEnclosureProcessor
+	Arrays       []Array
+	CurrentArray *Array `json:"-"`
+}
+
+type Array struct {
+	Id          rune
+	Type        string
+	UnusedSpace uint64
+	Drives      []Drive
+	// Spares holds drives listed under a "Spare" block: assigned to the
+	// array but not currently part of any logical drive.
+	Spares []Drive
+}
+
+type Drive struct {
+	Id       string // index or port:box:bay id, might be redundant
+	RaidMode string
+	Status   string
+	Size     uint64
+	Physical bool
+	// below properties are set only for physical drives
+	Type string
+	Port string
+	Box  uint
+	Bay  uint
+}
+
+// output-tailored regular expressions
+var ctlRx *regexp.Regexp = regexp.MustCompile("^(.*?) in Slot (\\d+) \\(([^\\)]+)\\)\\s+\\(sn: ([^\\)]+)\\)$")
+var sepRx *regexp.Regexp = regexp.MustCompile("^SEP\\s+\\(Vendor ID\\s+([^,]+),\\s+Model  ([^\\)]+)\\)\\s+(\\d+)\\s+\\(WWID:\\s+([^\\)]+)\\)$")
+var arrRx *regexp.Regexp = regexp.MustCompile("^array\\s+([A-Z])\\s+\\(([^,]+),\\s+Unused\\s+Space:([^\\)]+)\\)$")
+var szRx *regexp.Regexp = regexp.MustCompile(`^\s*((\d+)(\.\d+)?)\s*((K|M|G|T|P|E)B)?$`)
+var logRx *regexp.Regexp = regexp.MustCompile("^(\\d+)\\s+\\(([^,]+),\\s+([^,]+),\\s+([^\\)]+)\\)$")
+var physRx *regexp.Regexp = regexp.MustCompile("^([^\\s]+)\\s+\\(port\\s+([^:]+):box\\s+([^:]+):bay\\s+(\\d+),\\s+([^,]+),\\s+([^,]+),\\s+([^\\)]+)\\)$")
+
+func (ctl *Controller) Describe() string {
+	return fmt.Sprintf("%s in slot %d", ctl.Name, ctl.Slot)
+}
+
+func (arr *Array) Describe() string {
+	return fmt.Sprintf("%c (%s)", arr.Id, arr.Type)
+}
+
+func logn(n, b float64) float64 {
+	return math.Log(n) / math.Log(b)
+}
+
+// ConvertBytesToHumanReadable comes from
+// https://github.com/dustin/go-humanize/blob/master/bytes.go under MIT
+// license.
+func ConvertBytesToHumanReadable(s uint64) string {
+	base := float64(1000)
+
+	sizes := []string{"", "KB", "MB", "GB", "TB", "PB", "EB"}
+	if s < 10 {
+		return fmt.Sprintf("%d", s)
+	}
+	e := math.Floor(logn(float64(s), base))
+	suffix := sizes[int(e)]
+	val := math.Floor(float64(s)/math.Pow(base, e)*10+0.5) / 10
+	f := "%.0f%s"
+	if val < 10 {
+		f = "%.1f%s"
+	}
+	return fmt.Sprintf(f, val, suffix)
+}
+
+func (d *Drive) Describe() string {
+	var driveType, mode string
+	if d.Physical {
+		driveType = "physical"
+		mode = d.Type
+	} else {
+		driveType = "logical"
+		mode = d.RaidMode
+	}
+
+	return fmt.Sprintf("%s %s (%s, %s)", driveType, d.Id, mode, ConvertBytesToHumanReadable(d.Size))
+}
+
+func ControllerParse(s string) (*Controller, error) {
+	var ctl Controller
+
+	matched := ctlRx.FindStringSubmatch(s)
+	if matched == nil {
+		return nil, fmt.Errorf("not a controller header: %q", s)
+	}
+
+	ctl.Name = matched[1]
+	ui, err := strconv.ParseUint(matched[2], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid slot in %q: %w", s, err)
+	}
+	ctl.Slot = uint(ui)
+	ctl.Type = matched[3]
+	ctl.SerialNumber = matched[4]
+
+	return &ctl, nil
+}
+
+// convertHumanReadableToBytes parses a size such as "1.5 TB", "512MB"
+// or a bare number (already in bytes, as reported for some logical
+// drives).
+func convertHumanReadableToBytes(s string) (uint64, error) {
+	matched := szRx.FindStringSubmatch(s)
+	if matched == nil {
+		return 0, fmt.Errorf("not a size: %q", s)
+	}
+	n, err := strconv.ParseFloat(matched[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	if matched[5] == "" {
+		// no unit suffix: already in bytes
+		return uint64(n), nil
+	}
+
+	var mul uint64 = 1
+	switch matched[5][0] {
+	case 'E':
+		mul *= 1000
+		fallthrough
+	case 'P':
+		mul *= 1000
+		fallthrough
+	case 'T':
+		mul *= 1000
+		fallthrough
+	case 'G':
+		mul *= 1000
+		fallthrough
+	case 'M':
+		mul *= 1000
+		fallthrough
+	case 'K':
+		mul *= 1000
+	}
+
+	return uint64(n * float64(mul)), nil
+}
+
+func ArrayParse(s string) (*Array, error) {
+	var arr Array
+
+	matched := arrRx.FindStringSubmatch(s)
+	if matched == nil {
+		return nil, fmt.Errorf("not an array header: %q", s)
+	}
+	arr.Id = rune(matched[1][0])
+	arr.Type = matched[2]
+
+	unused, err := convertHumanReadableToBytes(matched[3])
+	if err != nil {
+		return nil, fmt.Errorf("array %q unused space: %w", s, err)
+	}
+	arr.UnusedSpace = unused
+
+	return &arr, nil
+}
+
+func DriveParse(s string) (*Drive, error) {
+	var d Drive
+	if strings.HasPrefix(s, "logicaldrive") {
+		matched := logRx.FindStringSubmatch(s[len("logicaldrive")+1:])
+		if matched == nil {
+			return nil, fmt.Errorf("not a logicaldrive line: %q", s)
+		}
+
+		size, err := convertHumanReadableToBytes(matched[2])
+		if err != nil {
+			return nil, fmt.Errorf("logicaldrive %q size: %w", s, err)
+		}
+
+		d.Id = matched[1]
+		d.Size = size
+		d.RaidMode = matched[3]
+		d.Status = matched[4]
+		d.Physical = false
+	} else if strings.HasPrefix(s, "physicaldrive") {
+		matched := physRx.FindStringSubmatch(s[len("physicaldrive")+1:])
+		if matched == nil {
+			return nil, fmt.Errorf("not a physicaldrive line: %q", s)
+		}
+
+		d.Id = matched[1]
+		d.Port = matched[2]
+		ui, err := strconv.ParseUint(matched[3], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("physicaldrive %q box: %w", s, err)
+		}
+		d.Box = uint(ui)
+		ui, err = strconv.ParseUint(matched[4], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("physicaldrive %q bay: %w", s, err)
+		}
+		d.Bay = uint(ui)
+		d.Type = matched[5]
+
+		size, err := convertHumanReadableToBytes(matched[6])
+		if err != nil {
+			return nil, fmt.Errorf("physicaldrive %q size: %w", s, err)
+		}
+		d.Size = size
+		d.Status = matched[7]
+		d.Physical = true
+	} else {
+		return nil, fmt.Errorf("cannot determine drive type: %q", s)
+	}
+
+	return &d, nil
+}
+
+func (ctl *Controller) Add(a *Array) {
+	ctl.Arrays = append(ctl.Arrays, *a)
+	ctl.CurrentArray = &ctl.Arrays[len(ctl.Arrays)-1]
+}
+
+func (arr *Array) Add(d *Drive) {
+	arr.Drives = append(arr.Drives, *d)
+}
+
+// AddSpare appends a drive to the array's Spare block.
+func (arr *Array) AddSpare(d *Drive) {
+	arr.Spares = append(arr.Spares, *d)
+}
+
+func (sep *StorageEnclosureProcessor) Parse(s string) error {
+	matched := sepRx.FindStringSubmatch(s)
+	if matched == nil {
+		return fmt.Errorf("not a SEP line: %q", s)
+	}
+	sep.VendorId = matched[1]
+	sep.Model = matched[2]
+	ui, err := strconv.ParseUint(matched[3], 10, 32)
+	if err != nil {
+		return fmt.Errorf("SEP %q expander: %w", s, err)
+	}
+	sep.Expander = uint(ui)
+	sep.WWID = matched[4]
+	return nil
+}
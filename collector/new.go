@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// New builds the Collector named by a --source flag value: "stdin",
+// "file:PATH", "hpacucli" or "ssacli". "storcli" is recognized but
+// currently rejected with an explanatory error, since storcli64's
+// output format is not yet supported by ParseControllers. strict
+// controls whether unrecognized output lines abort parsing or are
+// logged and skipped; see ParseControllers.
+func New(source string, strict bool) (Collector, error) {
+	switch {
+	case source == "" || source == "stdin":
+		return StdinCollector{Strict: strict, Logw: os.Stderr}, nil
+	case strings.HasPrefix(source, "file:"):
+		return FileCollector{Path: strings.TrimPrefix(source, "file:"), Strict: strict, Logw: os.Stderr}, nil
+	case source == "hpacucli" || source == "ssacli" || source == "storcli":
+		return NewExecCollector(source, strict)
+	default:
+		return nil, fmt.Errorf("unknown --source %q", source)
+	}
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// StdinCollector reads a pre-captured "ctrl all show config detail"
+// dump from standard input. This is the historical behaviour of
+// hpraidmon, kept as the default collector.
+type StdinCollector struct {
+	// Strict selects whether unrecognized lines abort parsing (true) or
+	// are logged to Logw and skipped (false, the zero value).
+	Strict bool
+	Logw   io.Writer
+}
+
+func (c StdinCollector) Collect(ctx context.Context) ([]*Controller, error) {
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	return ParseControllers(data, c.Strict, logWriter(c.Logw))
+}
+
+// FileCollector reads a pre-captured dump from a file on disk, for
+// "--source=file:PATH".
+type FileCollector struct {
+	Path   string
+	Strict bool
+	Logw   io.Writer
+}
+
+func (f FileCollector) Collect(ctx context.Context) ([]*Controller, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseControllers(data, f.Strict, logWriter(f.Logw))
+}
+
+func logWriter(w io.Writer) io.Writer {
+	if w == nil {
+		return os.Stderr
+	}
+	return w
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ignoredLinePrefixes lists lines that are known structural or
+// informational noise in real-world hpacucli/ssacli output:
+// they carry no data our model tracks, but seeing them should never be
+// treated as a parse failure.
+var ignoredLinePrefixes = []string{
+	"Enclosure SEP",
+	"Port Name:",
+	"Drive Cage",
+	"Cache Board",
+	"Battery/Capacitor",
+	"Controller Status:",
+	"Internal Drive Cage",
+	"Encryption Status:",
+}
+
+func isIgnoredLine(trimmed string) bool {
+	for _, prefix := range ignoredLinePrefixes {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseControllers parses the output of hpacucli/ssacli
+// "ctrl all show config detail" into a tree of controllers, arrays and
+// drives. Section headers (controller, SEP, array, unassigned, Spare)
+// are recognized by keyword rather than by indentation depth, since
+// real-world output mixes spare-drive blocks and informational lines
+// (enclosure SEP, cache/battery status, port names) at varying depths.
+//
+// In strict mode (the default) any line that cannot be classified
+// returns a *ParseError and aborts. With strict=false, unrecognized
+// lines are logged to logw and skipped instead, so one malformed
+// controller does not take down monitoring of every other controller.
+func ParseControllers(data []byte, strict bool, logw io.Writer) ([]*Controller, error) {
+	var currentController *Controller
+	var controllers []*Controller
+	inSpareBlock := false
+
+	skip := func(lineNo int, line string, reason string) error {
+		if strict {
+			return &ParseError{Line: lineNo, Content: line, Reason: reason}
+		}
+		fmt.Fprintf(logw, "skipping unrecognized line %d (%s): %s\n", lineNo, reason, line)
+		return nil
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		switch {
+		case ctlRx.MatchString(trimmed):
+			ctl, err := ControllerParse(trimmed)
+			if err != nil {
+				return nil, &ParseError{lineNo, line, err.Error()}
+			}
+
+			ctl.Arrays = []Array{{Id: 'U', Type: "unassigned"}}
+			ctl.CurrentArray = &ctl.Arrays[0]
+
+			currentController = ctl
+			inSpareBlock = false
+			controllers = append(controllers, ctl)
+
+		case strings.HasPrefix(trimmed, "SEP"):
+			if currentController == nil {
+				return nil, &ParseError{lineNo, line, "SEP line outside of any controller"}
+			}
+			if err := currentController.SEP.Parse(trimmed); err != nil {
+				if err := skip(lineNo, line, err.Error()); err != nil {
+					return nil, err
+				}
+			}
+
+		case trimmed == "unassigned":
+			if currentController == nil {
+				return nil, &ParseError{lineNo, line, "unassigned block outside of any controller"}
+			}
+			currentController.CurrentArray = &currentController.Arrays[0]
+			inSpareBlock = false
+
+		case trimmed == "Spare":
+			if currentController == nil || currentController.CurrentArray == nil {
+				return nil, &ParseError{lineNo, line, "Spare block outside of any array"}
+			}
+			inSpareBlock = true
+
+		case strings.HasPrefix(trimmed, "array "):
+			if currentController == nil {
+				return nil, &ParseError{lineNo, line, "array header outside of any controller"}
+			}
+			arr, err := ArrayParse(trimmed)
+			if err != nil {
+				if err := skip(lineNo, line, err.Error()); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			currentController.Add(arr)
+			inSpareBlock = false
+
+		case strings.HasPrefix(trimmed, "logicaldrive ") || strings.HasPrefix(trimmed, "physicaldrive "):
+			if currentController == nil || currentController.CurrentArray == nil {
+				return nil, &ParseError{lineNo, line, "drive line outside of any array"}
+			}
+			d, err := DriveParse(trimmed)
+			if err != nil {
+				if err := skip(lineNo, line, err.Error()); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if inSpareBlock {
+				currentController.CurrentArray.AddSpare(d)
+			} else {
+				currentController.CurrentArray.Add(d)
+			}
+
+		case isIgnoredLine(trimmed):
+			// known-benign structural/informational line, not part of the model
+
+		default:
+			if err := skip(lineNo, line, "unrecognized line"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return controllers, nil
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// arrayCounts is the expected drive/spare tally for one array within a
+// testdata fixture, keyed by array Id ('U' for the implicit unassigned
+// array).
+type arrayCounts struct {
+	id     rune
+	drives int
+	spares int
+}
+
+var textparseFixtures = []struct {
+	path   string
+	arrays []arrayCounts
+}{
+	{"../testdata/e208i.txt", []arrayCounts{
+		{'U', 0, 0},
+		{'A', 3, 0},
+	}},
+	{"../testdata/p400.txt", []arrayCounts{
+		{'U', 1, 0},
+		{'A', 3, 1},
+	}},
+	{"../testdata/p420i.txt", []arrayCounts{
+		{'U', 1, 0},
+		{'A', 4, 0},
+		{'B', 4, 0},
+	}},
+	{"../testdata/p440ar.txt", []arrayCounts{
+		{'U', 1, 0},
+		{'A', 3, 0},
+	}},
+}
+
+// TestParseControllersFixtures runs ParseControllers over every recorded
+// testdata/*.txt dump in strict mode and checks the resulting controller
+// has the expected arrays and drive/spare counts. This is the guard rail
+// that catches a fixture containing an informational line missing from
+// ignoredLinePrefixes (e208i.txt's "Encryption Status:" line is one that
+// is listed there).
+func TestParseControllersFixtures(t *testing.T) {
+	for _, fixture := range textparseFixtures {
+		fixture := fixture
+		t.Run(fixture.path, func(t *testing.T) {
+			data, err := ioutil.ReadFile(fixture.path)
+			if err != nil {
+				t.Fatalf("reading %s: %v", fixture.path, err)
+			}
+
+			controllers, err := ParseControllers(data, true, io.Discard)
+			if err != nil {
+				t.Fatalf("ParseControllers(%s): %v", fixture.path, err)
+			}
+			if len(controllers) != 1 {
+				t.Fatalf("ParseControllers(%s): got %d controllers, want 1", fixture.path, len(controllers))
+			}
+
+			ctl := controllers[0]
+			if len(ctl.Arrays) != len(fixture.arrays) {
+				t.Fatalf("ParseControllers(%s): got %d arrays, want %d", fixture.path, len(ctl.Arrays), len(fixture.arrays))
+			}
+			for i, want := range fixture.arrays {
+				arr := ctl.Arrays[i]
+				if arr.Id != want.id {
+					t.Errorf("ParseControllers(%s): array %d has Id %q, want %q", fixture.path, i, arr.Id, want.id)
+				}
+				if len(arr.Drives) != want.drives {
+					t.Errorf("ParseControllers(%s): array %c has %d drives, want %d", fixture.path, arr.Id, len(arr.Drives), want.drives)
+				}
+				if len(arr.Spares) != want.spares {
+					t.Errorf("ParseControllers(%s): array %c has %d spares, want %d", fixture.path, arr.Id, len(arr.Spares), want.spares)
+				}
+			}
+		})
+	}
+}
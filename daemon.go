@@ -0,0 +1,129 @@
+/*
+ * hpraidmon - long-running --daemon mode
+ *
+ * Polls a collector.Collector on a fixed interval and emits one JSON
+ * line per state transition (see events.go) to stdout, optionally
+ * POSTing the same payload to a webhook. The last snapshot is kept in
+ * memory and, when --state-file is set, persisted to disk so a restart
+ * does not re-fire every event as if all drives had just appeared.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gdm85/hpraidmon/collector"
+)
+
+// webhookTimeout bounds each --webhook POST. RunDaemon's loop is
+// sequential (collect, diff, emit, wait for the ticker), so a hung or
+// slow webhook receiver would otherwise stall every future poll
+// indefinitely.
+const webhookTimeout = 10 * time.Second
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// RunDaemon polls c every interval until SIGINT/SIGTERM is received. It
+// returns the exit code the process should use.
+func RunDaemon(c collector.Collector, interval time.Duration, webhook, stateFile string, unusedSpaceThreshold uint64) int {
+	prev, err := loadState(stateFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loading --state-file:", err)
+		return STATE_UNKNOWN
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		curr, err := c.Collect(context.Background())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			for _, ev := range DiffControllers(prev, curr, unusedSpaceThreshold) {
+				emitEvent(os.Stdout, ev, webhook)
+			}
+			prev = curr
+			if stateFile != "" {
+				if err := saveState(stateFile, curr); err != nil {
+					fmt.Fprintln(os.Stderr, "saving --state-file:", err)
+				}
+			}
+		}
+
+		select {
+		case <-sigCh:
+			return STATE_OK
+		case <-ticker.C:
+		}
+	}
+}
+
+func emitEvent(w *os.File, ev Event, webhook string) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "marshaling event:", err)
+		return
+	}
+
+	fmt.Fprintln(w, string(data))
+
+	if webhook == "" {
+		return
+	}
+	if err := postWebhook(webhook, data); err != nil {
+		fmt.Fprintln(os.Stderr, "webhook delivery failed:", err)
+	}
+}
+
+func postWebhook(url string, body []byte) error {
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func loadState(path string) ([]*Controller, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var controllers []*Controller
+	if err := json.Unmarshal(data, &controllers); err != nil {
+		return nil, err
+	}
+	return controllers, nil
+}
+
+func saveState(path string, controllers []*Controller) error {
+	data, err := json.Marshal(controllers)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,206 @@
+/*
+ * hpraidmon - state-transition events for --daemon mode
+ *
+ * Event diffing keys drives by (controller.SerialNumber, array.Id,
+ * drive.Id) and arrays by (controller.SerialNumber, array.Id), so a
+ * transition survives controllers being re-enumerated in a different
+ * order between polls.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Event is a single JSON-lines record describing a state transition
+// observed between two consecutive daemon polls.
+type Event struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Type       string    `json:"type"`
+	Controller string    `json:"controller"`
+	Array      string    `json:"array,omitempty"`
+	Drive      string    `json:"drive,omitempty"`
+	OldValue   string    `json:"old_value,omitempty"`
+	NewValue   string    `json:"new_value,omitempty"`
+}
+
+type driveSnapshot struct {
+	controller string
+	array      string
+	drive      string
+	status     string
+}
+
+type arraySnapshot struct {
+	controller  string
+	array       string
+	unusedSpace uint64
+}
+
+func snapshotDrives(controllers []*Controller) map[string]driveSnapshot {
+	snapshots := make(map[string]driveSnapshot)
+	for _, c := range controllers {
+		for ai := range c.Arrays {
+			a := &c.Arrays[ai]
+			for di := range a.Drives {
+				d := &a.Drives[di]
+				key := fmt.Sprintf("%s/%c/%s", c.SerialNumber, a.Id, d.Id)
+				snapshots[key] = driveSnapshot{
+					controller: c.Name,
+					array:      string(a.Id),
+					drive:      d.Id,
+					status:     d.Status,
+				}
+			}
+			for di := range a.Spares {
+				d := &a.Spares[di]
+				key := fmt.Sprintf("%s/%c/spare:%s", c.SerialNumber, a.Id, d.Id)
+				snapshots[key] = driveSnapshot{
+					controller: c.Name,
+					array:      string(a.Id),
+					drive:      d.Id,
+					status:     d.Status,
+				}
+			}
+		}
+	}
+	return snapshots
+}
+
+func snapshotArrays(controllers []*Controller) map[string]arraySnapshot {
+	snapshots := make(map[string]arraySnapshot)
+	for _, c := range controllers {
+		for ai := range c.Arrays {
+			a := &c.Arrays[ai]
+			key := fmt.Sprintf("%s/%c", c.SerialNumber, a.Id)
+			snapshots[key] = arraySnapshot{
+				controller:  c.Name,
+				array:       string(a.Id),
+				unusedSpace: a.UnusedSpace,
+			}
+		}
+	}
+	return snapshots
+}
+
+func sortedKeys(prev, curr map[string]bool) []string {
+	keys := make([]string, 0, len(prev)+len(curr))
+	for k := range prev {
+		keys = append(keys, k)
+	}
+	for k := range curr {
+		if !prev[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// driveStatusEvent classifies a drive status transition: a drive
+// (logical or physical) entering/leaving the "Rebuilding" status gets its own event
+// type, everything else is a generic status change.
+func driveStatusEvent(now time.Time, s driveSnapshot, oldStatus string) Event {
+	eventType := "status_change"
+	switch {
+	case s.status == "Rebuilding" && oldStatus != "Rebuilding":
+		eventType = "rebuild_started"
+	case oldStatus == "Rebuilding" && s.status != "Rebuilding":
+		eventType = "rebuild_finished"
+	}
+	return Event{
+		Timestamp:  now,
+		Type:       eventType,
+		Controller: s.controller,
+		Array:      s.array,
+		Drive:      s.drive,
+		OldValue:   oldStatus,
+		NewValue:   s.status,
+	}
+}
+
+// DiffControllers compares two snapshots and returns the events that
+// bring prev up to curr. An unused_space_changed event is emitted only
+// when the change is larger than unusedSpaceThreshold bytes.
+func DiffControllers(prev, curr []*Controller, unusedSpaceThreshold uint64) []Event {
+	now := time.Now()
+	var events []Event
+
+	prevDrives := snapshotDrives(prev)
+	currDrives := snapshotDrives(curr)
+	present := make(map[string]bool, len(prevDrives))
+	for k := range prevDrives {
+		present[k] = true
+	}
+	for _, key := range sortedKeys(present, toBoolMap(currDrives)) {
+		old, hadOld := prevDrives[key]
+		cur, hasCur := currDrives[key]
+		switch {
+		case hadOld && !hasCur:
+			events = append(events, Event{
+				Timestamp: now, Type: "drive_removed",
+				Controller: old.controller, Array: old.array, Drive: old.drive,
+				OldValue: old.status,
+			})
+		case !hadOld && hasCur:
+			events = append(events, Event{
+				Timestamp: now, Type: "drive_added",
+				Controller: cur.controller, Array: cur.array, Drive: cur.drive,
+				NewValue: cur.status,
+			})
+		case old.status != cur.status:
+			events = append(events, driveStatusEvent(now, cur, old.status))
+		}
+	}
+
+	prevArrays := snapshotArrays(prev)
+	currArrays := snapshotArrays(curr)
+	present = make(map[string]bool, len(prevArrays))
+	for k := range prevArrays {
+		present[k] = true
+	}
+	for _, key := range sortedKeys(present, toBoolMapArrays(currArrays)) {
+		old, hadOld := prevArrays[key]
+		cur, hasCur := currArrays[key]
+		if !hadOld || !hasCur {
+			// array appearing/disappearing is already covered by its drives
+			continue
+		}
+		if diffUint64(old.unusedSpace, cur.unusedSpace) > unusedSpaceThreshold {
+			events = append(events, Event{
+				Timestamp: now, Type: "unused_space_changed",
+				Controller: cur.controller, Array: cur.array,
+				OldValue: fmt.Sprintf("%d", old.unusedSpace),
+				NewValue: fmt.Sprintf("%d", cur.unusedSpace),
+			})
+		}
+	}
+
+	return events
+}
+
+func toBoolMap(m map[string]driveSnapshot) map[string]bool {
+	out := make(map[string]bool, len(m))
+	for k := range m {
+		out[k] = true
+	}
+	return out
+}
+
+func toBoolMapArrays(m map[string]arraySnapshot) map[string]bool {
+	out := make(map[string]bool, len(m))
+	for k := range m {
+		out[k] = true
+	}
+	return out
+}
+
+func diffUint64(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,383 @@
+/*
+ * hpraidmon - filter expression language for selecting drives
+ *
+ * Grammar (informal):
+ *
+ *   expr       := orExpr
+ *   orExpr     := andExpr ( "||" andExpr )*
+ *   andExpr    := unary ( ( "&&" | "," ) unary )*
+ *   unary      := "(" expr ")" | comparison
+ *   comparison := FIELD COMPARATOR VALUE
+ *
+ * FIELD is one of: name, slot, array, id, type, raid_mode, status, size,
+ * port, box, bay, physical. COMPARATOR is one of =, ==, !=, <, <=, >, >=
+ * ("==" is accepted as a synonym for "=", since it is the single most
+ * natural typo for anyone used to C-family languages).
+ * A comma is accepted as a synonym for "&&", so that
+ * "type=SAS,size>1TB,status!=Predictive Failure" reads like a short-hand
+ * AND chain, similar to the field/comparator filters used by common
+ * issue trackers.
+ */
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var comparisonRx = regexp.MustCompile(`^\s*([a-zA-Z_]+)\s*(!=|<=|>=|==|=|<|>)\s*(.+?)\s*$`)
+var filterSizeRx = regexp.MustCompile(`(?i)^((\d+)(\.\d+)?)\s*((K|M|G|T|P|E)B?)?$`)
+
+// Predicate is a boolean test evaluated against a single drive, in the
+// context of the controller and array that contain it.
+type Predicate interface {
+	Eval(ctx filterContext) bool
+}
+
+// filterContext carries the objects a Predicate may pull fields from.
+type filterContext struct {
+	controller *Controller
+	array      *Array
+	drive      *Drive
+}
+
+// numericFields lists the fields that should be compared as numbers
+// (with size accepting human-readable suffixes like "1TB") rather than
+// as plain strings.
+var numericFields = map[string]bool{
+	"slot": true,
+	"size": true,
+	"box":  true,
+	"bay":  true,
+}
+
+// field resolves a filter field name to its string representation for
+// the current drive/array/controller triple. The second return value is
+// false if the field name is not recognized.
+func (ctx filterContext) field(name string) (string, bool) {
+	switch name {
+	case "name":
+		return ctx.controller.Name, true
+	case "slot":
+		return strconv.FormatUint(uint64(ctx.controller.Slot), 10), true
+	case "array":
+		return string(ctx.array.Id), true
+	case "id":
+		return ctx.drive.Id, true
+	case "type":
+		if ctx.drive.Physical {
+			return ctx.drive.Type, true
+		}
+		return ctx.array.Type, true
+	case "raid_mode":
+		return ctx.drive.RaidMode, true
+	case "status":
+		return ctx.drive.Status, true
+	case "size":
+		return strconv.FormatUint(ctx.drive.Size, 10), true
+	case "port":
+		return ctx.drive.Port, true
+	case "box":
+		return strconv.FormatUint(uint64(ctx.drive.Box), 10), true
+	case "bay":
+		return strconv.FormatUint(uint64(ctx.drive.Bay), 10), true
+	case "physical":
+		if ctx.drive.Physical {
+			return "true", true
+		}
+		return "false", true
+	}
+	return "", false
+}
+
+type andPredicate []Predicate
+
+func (p andPredicate) Eval(ctx filterContext) bool {
+	for _, sub := range p {
+		if !sub.Eval(ctx) {
+			return false
+		}
+	}
+	return true
+}
+
+type orPredicate []Predicate
+
+func (p orPredicate) Eval(ctx filterContext) bool {
+	for _, sub := range p {
+		if sub.Eval(ctx) {
+			return true
+		}
+	}
+	return false
+}
+
+type comparisonPredicate struct {
+	field string
+	op    string
+	value string
+}
+
+func (p comparisonPredicate) Eval(ctx filterContext) bool {
+	actual, ok := ctx.field(p.field)
+	if !ok {
+		return false
+	}
+
+	if numericFields[p.field] {
+		a, aok := parseFilterNumber(actual)
+		b, bok := parseFilterNumber(p.value)
+		if aok && bok {
+			return compareNumbers(a, b, p.op)
+		}
+	}
+
+	return compareStrings(actual, p.value, p.op)
+}
+
+func compareNumbers(a, b float64, op string) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func compareStrings(a, b, op string) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+// parseFilterNumber parses either a plain number or a human-readable
+// byte size such as "1TB" or "512 GB" (case-insensitive, space optional).
+func parseFilterNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+
+	matched := filterSizeRx.FindStringSubmatch(s)
+	if matched == nil {
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, false
+		}
+		return n, true
+	}
+
+	n, err := strconv.ParseFloat(matched[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	if matched[4] == "" {
+		return n, true
+	}
+
+	mul := 1.0
+	switch matched[4][0] {
+	case 'e', 'E':
+		mul *= 1000
+		fallthrough
+	case 'p', 'P':
+		mul *= 1000
+		fallthrough
+	case 't', 'T':
+		mul *= 1000
+		fallthrough
+	case 'g', 'G':
+		mul *= 1000
+		fallthrough
+	case 'm', 'M':
+		mul *= 1000
+		fallthrough
+	case 'k', 'K':
+		mul *= 1000
+	}
+	return n * mul, true
+}
+
+// ParseError describes a malformed filter expression.
+type ParseError struct {
+	Expr   string
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid filter expression %q: %s", e.Expr, e.Reason)
+}
+
+// ParseFilter compiles a filter expression into a Predicate tree.
+func ParseFilter(expr string) (Predicate, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, &ParseError{expr, "empty expression"}
+	}
+	return parseOr(expr)
+}
+
+func parseOr(s string) (Predicate, error) {
+	parts := splitTopLevel(s, "||")
+	if len(parts) == 1 {
+		return parseAnd(parts[0])
+	}
+
+	var preds orPredicate
+	for _, part := range parts {
+		p, err := parseAnd(part)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, p)
+	}
+	return preds, nil
+}
+
+func parseAnd(s string) (Predicate, error) {
+	parts := splitTopLevelAny(s, "&&", ",")
+	if len(parts) == 1 {
+		return parseUnary(parts[0])
+	}
+
+	var preds andPredicate
+	for _, part := range parts {
+		p, err := parseUnary(part)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, p)
+	}
+	return preds, nil
+}
+
+func parseUnary(s string) (Predicate, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") && isBalancedGroup(s) {
+		return parseOr(s[1 : len(s)-1])
+	}
+	return parseComparison(s)
+}
+
+func parseComparison(s string) (Predicate, error) {
+	matched := comparisonRx.FindStringSubmatch(s)
+	if matched == nil {
+		return nil, &ParseError{s, "expected FIELD<op>VALUE"}
+	}
+
+	field := matched[1]
+	if _, ok := (filterContext{}).fieldNames()[field]; !ok {
+		return nil, &ParseError{s, "unknown field " + field}
+	}
+
+	op := matched[2]
+	if op == "==" {
+		op = "="
+	}
+
+	return comparisonPredicate{
+		field: field,
+		op:    op,
+		value: strings.TrimSpace(matched[3]),
+	}, nil
+}
+
+func (filterContext) fieldNames() map[string]bool {
+	return map[string]bool{
+		"name": true, "slot": true, "array": true, "id": true, "type": true,
+		"raid_mode": true, "status": true, "size": true, "port": true,
+		"box": true, "bay": true, "physical": true,
+	}
+}
+
+// splitTopLevel splits s on every occurrence of sep that is not nested
+// inside parentheses.
+func splitTopLevel(s, sep string) []string {
+	return splitTopLevelAny(s, sep)
+}
+
+// splitTopLevelAny splits s on any of the given separators, as long as
+// the occurrence is not nested inside parentheses.
+func splitTopLevelAny(s string, seps ...string) []string {
+	var parts []string
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '(':
+			depth++
+			i++
+		case s[i] == ')':
+			depth--
+			i++
+		case depth == 0 && matchesAny(s, i, seps):
+			sep := matchedSep(s, i, seps)
+			parts = append(parts, s[start:i])
+			i += len(sep)
+			start = i
+		default:
+			i++
+		}
+	}
+	parts = append(parts, s[start:])
+	return parts
+}
+
+func matchesAny(s string, i int, seps []string) bool {
+	return matchedSep(s, i, seps) != ""
+}
+
+func matchedSep(s string, i int, seps []string) string {
+	for _, sep := range seps {
+		if strings.HasPrefix(s[i:], sep) {
+			return sep
+		}
+	}
+	return ""
+}
+
+// isBalancedGroup returns true if s starts and ends with a matching
+// pair of parentheses that enclose the whole string (rather than s
+// merely starting with "(" and ending with ")" while actually being two
+// separate parenthesized groups joined by an operator).
+func isBalancedGroup(s string) bool {
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && i != len(s)-1 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
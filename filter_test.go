@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdm85/hpraidmon/collector"
+)
+
+func testContext() filterContext {
+	ctl := &collector.Controller{Name: "Smart Array P420i", Slot: 0}
+	arr := &collector.Array{Id: 'A', Type: "SAS"}
+	drive := &collector.Drive{Id: "1I:1:1", Status: "OK", Size: 300000000000, Physical: true, Type: "SAS"}
+	return filterContext{controller: ctl, array: arr, drive: drive}
+}
+
+func evalExpr(t *testing.T, expr string) bool {
+	t.Helper()
+	p, err := ParseFilter(expr)
+	if err != nil {
+		t.Fatalf("ParseFilter(%q): %v", expr, err)
+	}
+	return p.Eval(testContext())
+}
+
+func TestParseFilterOperators(t *testing.T) {
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{"status=OK", true},
+		{"status==OK", true},
+		{"status == OK", true},
+		{"status!=OK", false},
+		{"status=Failed", false},
+		{"size>1TB", false},
+		{"size<1TB", true},
+		{"size>=300GB", true},
+		{"size<=300GB", true},
+	}
+	for _, c := range cases {
+		if got := evalExpr(t, c.expr); got != c.want {
+			t.Errorf("ParseFilter(%q).Eval(...) = %v, want %v", c.expr, got, c.want)
+		}
+	}
+}
+
+// TestParseFilterDoubleEqualsNeverMatchesLiteralOperator guards against a
+// regression where "==" was not recognized as a synonym for "=": the
+// leftover "=" used to get folded into the value, so "status==OK" would
+// silently compare against the literal string "=OK" and never match.
+func TestParseFilterDoubleEqualsNeverMatchesLiteralOperator(t *testing.T) {
+	p, err := ParseFilter("status==OK")
+	if err != nil {
+		t.Fatalf("ParseFilter: %v", err)
+	}
+	cp, ok := p.(comparisonPredicate)
+	if !ok {
+		t.Fatalf("ParseFilter(\"status==OK\") = %T, want comparisonPredicate", p)
+	}
+	if cp.op != "=" {
+		t.Errorf("comparisonPredicate.op = %q, want \"=\"", cp.op)
+	}
+	if cp.value != "OK" {
+		t.Errorf("comparisonPredicate.value = %q, want \"OK\"", cp.value)
+	}
+}
+
+func TestParseFilterUnknownField(t *testing.T) {
+	if _, err := ParseFilter("bogus=OK"); err == nil {
+		t.Fatal("ParseFilter(\"bogus=OK\") = nil error, want error for unknown field")
+	}
+}
+
+func TestParseFilterAndOr(t *testing.T) {
+	if !evalExpr(t, "status=OK && type=SAS") {
+		t.Error("expected status=OK && type=SAS to match")
+	}
+	if evalExpr(t, "status=Failed && type=SAS") {
+		t.Error("expected status=Failed && type=SAS to not match")
+	}
+	if !evalExpr(t, "status=Failed || type=SAS") {
+		t.Error("expected status=Failed || type=SAS to match")
+	}
+}
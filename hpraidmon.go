@@ -20,13 +20,13 @@ Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"math"
 	"os"
-	"regexp"
-	"strconv"
-	"strings"
+	"time"
+
+	"github.com/gdm85/hpraidmon/collector"
 )
 
 // Nagios-compatible exit codes
@@ -38,291 +38,86 @@ const (
 	STATE_DEPENDENT = 4
 )
 
-type StorageEnclosureProcessor struct {
-	VendorId string
-	Model    string
-	Expander uint
-	WWID     string
-}
+// the domain model and its parsing now live in package collector; these
+// aliases let the rest of package main keep referring to them by their
+// historical names
+type StorageEnclosureProcessor = collector.StorageEnclosureProcessor
+type Controller = collector.Controller
+type Array = collector.Array
+type Drive = collector.Drive
 
-type Controller struct {
-	Name         string
-	Type         string
-	Slot         uint
-	SerialNumber string
-	SEP          StorageEnclosureProcessor
-	Arrays       []Array
-	CurrentArray *Array
-}
-
-type Array struct {
-	Id          rune
-	Type        string
-	UnusedSpace uint64
-	Drives      []Drive
-}
+var convertBytesToHumanReadable = collector.ConvertBytesToHumanReadable
 
-type Drive struct {
-	Id       string // index or port:box:bay id, might be redundant
-	RaidMode string
-	Status   string
-	Size     uint64
-	Physical bool
-	// below properties are set only for physical drives
-	Type string
-	Port string
-	Box  uint
-	Bay  uint
-}
-
-// output-tailored regular expressions
-var ctlRx *regexp.Regexp = regexp.MustCompile("^(.*?) in Slot (\\d+) \\(([^\\)]+)\\)\\s+\\(sn: ([^\\)]+)\\)$")
-var sepRx *regexp.Regexp = regexp.MustCompile("^SEP\\s+\\(Vendor ID\\s+([^,]+),\\s+Model  ([^\\)]+)\\)\\s+(\\d+)\\s+\\(WWID:\\s+([^\\)]+)\\)$")
-var arrRx *regexp.Regexp = regexp.MustCompile("^array\\s+([A-Z])\\s+\\(([^,]+),\\s+Unused\\s+Space:([^\\)]+)\\)$")
-var szRx *regexp.Regexp = regexp.MustCompile("^\\s*((\\d+)(\\.\\d+)?)\\s+((K|M|G|T)B)?$")
-var logRx *regexp.Regexp = regexp.MustCompile("^(\\d+)\\s+\\(([^,]+),\\s+([^,]+),\\s+([^\\)]+)\\)$")
-var physRx *regexp.Regexp = regexp.MustCompile("^([^\\s]+)\\s+\\(port\\s+([^:]+):box\\s+([^:]+):bay\\s+(\\d+),\\s+([^,]+),\\s+([^,]+),\\s+([^\\)]+)\\)$")
-
-func (ctl *Controller) Describe() string {
-	return fmt.Sprintf("%s in slot %d", ctl.Name, ctl.Slot)
-}
-
-func (arr *Array) Describe() string {
-	return fmt.Sprintf("%c (%s)", arr.Id, arr.Type)
-}
-
-func logn(n, b float64) float64 {
-	return math.Log(n) / math.Log(b)
-}
-
-// this function comes from https://github.com/dustin/go-humanize/blob/master/bytes.go
-// under MIT license
-func convertBytesToHumanReadable(s uint64) string {
-	base := float64(1000)
-
-	sizes := []string{"", "KB", "MB", "GB", "TB", "PB", "EB"}
-	if s < 10 {
-		return fmt.Sprintf("%d", s)
-	}
-	e := math.Floor(logn(float64(s), base))
-	suffix := sizes[int(e)]
-	val := math.Floor(float64(s)/math.Pow(base, e)*10+0.5) / 10
-	f := "%.0f%s"
-	if val < 10 {
-		f = "%.1f%s"
-	}
-	return fmt.Sprintf(f, val, suffix)
-}
-
-func (d *Drive) Describe() string {
-	var driveType, mode string
-	if d.Physical {
-		driveType = "physical"
-		mode = d.Type
-	} else {
-		driveType = "logical"
-		mode = d.RaidMode
-	}
-
-	return fmt.Sprintf("%s %s (%s, %s)", driveType, d.Id, mode, convertBytesToHumanReadable(d.Size))
-}
-
-func ControllerParse(s string) *Controller {
-	var ctl Controller
-
-	matched := ctlRx.FindStringSubmatch(s)
-
-	ctl.Name = matched[1]
-	ui, err := strconv.ParseUint(matched[2], 10, 32)
-	if err != nil {
-		panic(err)
-	}
-	ctl.Slot = uint(ui)
-	ctl.Type = matched[3]
-	ctl.SerialNumber = matched[4]
-
-	return &ctl
-}
-
-func convertHumanReadableToBytes(s string) uint64 {
-	matched := szRx.FindStringSubmatch(s)
-	if len(matched) == 0 {
-		panic("no match for " + s)
-	}
-	n, _ := strconv.ParseFloat(matched[1], 64)
-
-	var mul uint64 = 1
-	switch matched[5][0] {
-	case 'E':
-		mul *= 1000
-		fallthrough
-	case 'P':
-		mul *= 1000
-		fallthrough
-	case 'T':
-		mul *= 1000
-		fallthrough
-	case 'G':
-		mul *= 1000
-		fallthrough
-	case 'M':
-		mul *= 1000
-		fallthrough
-	case 'K':
-		mul *= 1000
+func main() {
+	filterExpr := flag.String("filter", "", "only drives matching this expression trigger an alert (default: status!=OK); e.g. \"status!=OK && array!=U\"")
+	excludeExpr := flag.String("exclude", "", "drives matching this expression never trigger an alert, e.g. to silence known-degraded spares")
+	output := flag.String("output", "nagios", "output format: nagios|json|prometheus")
+	source := flag.String("source", "stdin", "where to read controller status from: stdin|file:PATH|hpacucli|ssacli (storcli is not supported yet; stdin cannot be combined with --daemon)")
+	daemon := flag.Bool("daemon", false, "keep polling the collector and emit JSON-lines events on state transitions, instead of a single-shot check")
+	interval := flag.Duration("interval", 60*time.Second, "polling interval in --daemon mode")
+	webhook := flag.String("webhook", "", "URL to POST each daemon event to, in addition to stdout")
+	stateFile := flag.String("state-file", "", "persist the last snapshot here so a daemon restart does not re-fire every event")
+	unusedThreshold := flag.Uint64("unused-threshold", 1e9, "unused-space changes larger than this many bytes emit a daemon event")
+	strict := flag.Bool("strict", true, "abort on unrecognized output lines instead of logging and skipping them")
+	flag.Parse()
+
+	switch *output {
+	case "nagios", "json", "prometheus":
 	default:
-		panic("Unknown size prefix")
+		fmt.Fprintf(os.Stderr, "unknown --output format %q\n", *output)
+		os.Exit(STATE_UNKNOWN)
 	}
 
-	return uint64(n * float64(mul))
-}
-
-func ArrayParse(s string) *Array {
-	var arr Array
-
-	matched := arrRx.FindStringSubmatch(s)
-	arr.Id = rune(matched[1][0])
-	arr.Type = matched[2]
-	arr.UnusedSpace = convertHumanReadableToBytes(matched[3])
-
-	return &arr
-}
-
-func DriveParse(s string) *Drive {
-	var d Drive
-	if strings.HasPrefix(s, "logicaldrive") {
-		matched := logRx.FindStringSubmatch(s[len("logicaldrive")+1:])
-
-		d.Id = matched[1]
-		d.Size = convertHumanReadableToBytes(matched[2])
-		d.RaidMode = matched[3]
-		d.Status = matched[4]
-		d.Physical = false
-	} else if strings.HasPrefix(s, "physicaldrive") {
-		matched := physRx.FindStringSubmatch(s[len("physicaldrive")+1:])
-
-		d.Id = matched[1]
-		d.Port = matched[2]
-		ui, err := strconv.ParseUint(matched[3], 10, 32)
+	var alertPredicate, excludePredicate Predicate
+	if *filterExpr != "" {
+		p, err := ParseFilter(*filterExpr)
 		if err != nil {
-			panic(err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(STATE_UNKNOWN)
 		}
-		d.Box = uint(ui)
-		ui, err = strconv.ParseUint(matched[4], 10, 32)
+		alertPredicate = p
+	}
+	if *excludeExpr != "" {
+		p, err := ParseFilter(*excludeExpr)
 		if err != nil {
-			panic(err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(STATE_UNKNOWN)
 		}
-		d.Bay = uint(ui)
-		d.Type = matched[5]
-		d.Size = convertHumanReadableToBytes(matched[6])
-		d.Status = matched[7]
-		d.Physical = true
-	} else {
-		panic("cannot determine drive type")
+		excludePredicate = p
 	}
 
-	return &d
-}
-
-func (ctl *Controller) Add(a *Array) {
-	ctl.Arrays = append(ctl.Arrays, *a)
-	ctl.CurrentArray = &ctl.Arrays[len(ctl.Arrays)-1]
-}
-
-func (arr *Array) Add(d *Drive) {
-	arr.Drives = append(arr.Drives, *d)
-}
-
-func (sep *StorageEnclosureProcessor) Parse(s string) {
-	matched := sepRx.FindStringSubmatch(s)
-	sep.VendorId = matched[1]
-	sep.Model = matched[2]
-	ui, err := strconv.ParseUint(matched[3], 10, 32)
-	if err != nil {
-		panic(err)
+	if *daemon && (*source == "" || *source == "stdin") {
+		fmt.Fprintln(os.Stderr, "--daemon cannot be used with --source=stdin: standard input can only be read once, so every poll after the first would see every drive as removed")
+		os.Exit(STATE_UNKNOWN)
 	}
-	sep.Expander = uint(ui)
-	sep.WWID = matched[4]
-}
 
-func main() {
-	bytes, err := ioutil.ReadAll(os.Stdin)
+	c, err := collector.New(*source, *strict)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(STATE_UNKNOWN)
 	}
 
-	var currentController *Controller
-	var controllers []*Controller
-
-	for lineNo, line := range strings.Split(string(bytes), "\n") {
-		if len(line) == 0 {
-			continue
-		}
-
-		// count number of trailing spaces
-		var i int
-		for i = 0; i < len(line); i++ {
-			if line[i] != ' ' {
-				break
-			}
-		}
-
-		switch i {
-		case 0:
-			currentController = ControllerParse(line[i:])
-
-			// create unassigned array
-			currentController.Arrays = []Array{
-				Array{
-					Id:   'U',
-					Type: "unassigned",
-				},
-			}
-
-			controllers = append(controllers, currentController)
-			break
-		case 3:
-			if strings.HasPrefix(line[i:], "SEP") {
-				currentController.SEP.Parse(line[i:])
-			} else if line[i:] == "unassigned" {
-				// already created for all controllers as currentController.Arrays[0]
-			} else {
-				currentController.Add(ArrayParse(line[i:]))
-			}
-		case 6:
-			currentController.CurrentArray.Add(DriveParse(line[i:]))
-			break
-		default:
-			panic(fmt.Sprintf("cannot parse line %d with %d trailing spaces:%s", lineNo, i, line))
-
-		}
+	if *daemon {
+		os.Exit(RunDaemon(c, *interval, *webhook, *stateFile, *unusedThreshold))
 	}
 
-	exitCode := STATE_OK
+	controllers, err := c.Collect(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(STATE_UNKNOWN)
+	}
 
-	// check that status of each drive (logical or physical) is OK
-	for _, controller := range controllers {
-		for _, array := range controller.Arrays {
-			for _, drive := range array.Drives {
-				if drive.Status != "OK" {
-					// print informational message about this drive
-					fmt.Fprintf(os.Stderr, "controller '%s', array '%s': drive '%s' status is %s\n", controller.Describe(), array.Describe(), drive.Describe(), drive.Status)
+	exitCode, issues := Evaluate(controllers, alertPredicate, excludePredicate)
 
-					// failures on disks that are not assigned are non-critical
-					if array.Id == 'U' {
-						exitCode = STATE_WARNING
-					} else {
-						// for some specific failure states, consider them not (yet) critical
-						if drive.Status == "Predictive Failure" {
-							exitCode = STATE_WARNING
-						} else {
-							// disk is not unassigned and this is not a predictive failure
-							exitCode = STATE_CRITICAL
-						}
-					}
-				}
-			}
+	switch *output {
+	case "json":
+		if err := RenderJSON(os.Stdout, controllers); err != nil {
+			panic(err)
 		}
+	case "prometheus":
+		RenderPrometheus(os.Stdout, controllers)
+	default:
+		RenderNagios(os.Stderr, issues)
 	}
 
 	os.Exit(exitCode)
@@ -0,0 +1,249 @@
+/*
+ * hpraidmon - shared status evaluation and output renderers
+ *
+ * Evaluate is the single source of truth for alerting: it walks the
+ * controller/array/drive tree once and produces both the Nagios exit
+ * code and the list of Issues behind it. The --output=json and
+ * --output=prometheus renderers dump the full tree instead (they are
+ * meant to feed other tooling, not just an alerting threshold), but
+ * still share the same size helper and driveSeverity as the rest of the
+ * program.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Issue describes a single drive whose status triggered an alert.
+type Issue struct {
+	Controller *Controller
+	Array      *Array
+	Drive      *Drive
+	Severity   int // STATE_WARNING or STATE_CRITICAL
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("controller '%s', array '%s': drive '%s' status is %s", i.Controller.Describe(), i.Array.Describe(), i.Drive.Describe(), i.Drive.Status)
+}
+
+// driveSeverity returns the Nagios severity of a single drive, independent
+// of whether it is selected by any --filter/--exclude expression. A
+// spare drive is treated like one on the unassigned array: not yet
+// carrying live data, so its failure is never critical on its own.
+func driveSeverity(array *Array, drive *Drive, isSpare bool) int {
+	if drive.Status == "OK" {
+		return STATE_OK
+	}
+	if isSpare || array.Id == 'U' {
+		return STATE_WARNING
+	}
+	if drive.Status == "Predictive Failure" {
+		return STATE_WARNING
+	}
+	return STATE_CRITICAL
+}
+
+// Evaluate walks controllers/arrays/drives (and spare drives) once and
+// returns the overall Nagios exit code together with the Issues that
+// drove it. A nil alertPredicate falls back to the historical
+// "status != OK" rule; a nil excludePredicate matches nothing.
+func Evaluate(controllers []*Controller, alertPredicate, excludePredicate Predicate) (exitCode int, issues []Issue) {
+	exitCode = STATE_OK
+
+	for _, controller := range controllers {
+		for ai := range controller.Arrays {
+			array := &controller.Arrays[ai]
+
+			evalDrives := func(drives []Drive, isSpare bool) {
+				for di := range drives {
+					drive := &drives[di]
+					ctx := filterContext{controller, array, drive}
+
+					if excludePredicate != nil && excludePredicate.Eval(ctx) {
+						continue
+					}
+
+					var alert bool
+					if alertPredicate != nil {
+						alert = alertPredicate.Eval(ctx)
+					} else {
+						alert = drive.Status != "OK"
+					}
+					if !alert {
+						continue
+					}
+
+					severity := driveSeverity(array, drive, isSpare)
+					if severity == STATE_OK {
+						continue
+					}
+					issues = append(issues, Issue{controller, array, drive, severity})
+					if severity > exitCode {
+						exitCode = severity
+					}
+				}
+			}
+
+			evalDrives(array.Drives, false)
+			evalDrives(array.Spares, true)
+		}
+	}
+
+	return exitCode, issues
+}
+
+// RenderNagios prints one line per issue to w, matching the original
+// plugin-style output.
+func RenderNagios(w io.Writer, issues []Issue) {
+	for _, issue := range issues {
+		fmt.Fprintln(w, issue.String())
+	}
+}
+
+type driveJSON struct {
+	Id        string `json:"id"`
+	RaidMode  string `json:"raid_mode,omitempty"`
+	Status    string `json:"status"`
+	SizeBytes uint64 `json:"size_bytes"`
+	SizeHuman string `json:"size_human"`
+	Physical  bool   `json:"physical"`
+	Type      string `json:"type,omitempty"`
+	Port      string `json:"port,omitempty"`
+	Box       uint   `json:"box,omitempty"`
+	Bay       uint   `json:"bay,omitempty"`
+}
+
+type arrayJSON struct {
+	Id               string      `json:"id"`
+	Type             string      `json:"type"`
+	UnusedSpaceBytes uint64      `json:"unused_space_bytes"`
+	UnusedSpaceHuman string      `json:"unused_space_human"`
+	Drives           []driveJSON `json:"drives"`
+	Spares           []driveJSON `json:"spares,omitempty"`
+}
+
+type controllerJSON struct {
+	Name         string      `json:"name"`
+	Type         string      `json:"type"`
+	Slot         uint        `json:"slot"`
+	SerialNumber string      `json:"serial_number"`
+	Arrays       []arrayJSON `json:"arrays"`
+}
+
+func toDriveJSON(drive Drive) driveJSON {
+	return driveJSON{
+		Id:        drive.Id,
+		RaidMode:  drive.RaidMode,
+		Status:    drive.Status,
+		SizeBytes: drive.Size,
+		SizeHuman: convertBytesToHumanReadable(drive.Size),
+		Physical:  drive.Physical,
+		Type:      drive.Type,
+		Port:      drive.Port,
+		Box:       drive.Box,
+		Bay:       drive.Bay,
+	}
+}
+
+func toControllerJSON(controllers []*Controller) []controllerJSON {
+	out := make([]controllerJSON, 0, len(controllers))
+	for _, controller := range controllers {
+		cj := controllerJSON{
+			Name:         controller.Name,
+			Type:         controller.Type,
+			Slot:         controller.Slot,
+			SerialNumber: controller.SerialNumber,
+			Arrays:       make([]arrayJSON, 0, len(controller.Arrays)),
+		}
+		for _, array := range controller.Arrays {
+			aj := arrayJSON{
+				Id:               string(array.Id),
+				Type:             array.Type,
+				UnusedSpaceBytes: array.UnusedSpace,
+				UnusedSpaceHuman: convertBytesToHumanReadable(array.UnusedSpace),
+				Drives:           make([]driveJSON, 0, len(array.Drives)),
+			}
+			for _, drive := range array.Drives {
+				aj.Drives = append(aj.Drives, toDriveJSON(drive))
+			}
+			for _, drive := range array.Spares {
+				aj.Spares = append(aj.Spares, toDriveJSON(drive))
+			}
+			cj.Arrays = append(cj.Arrays, aj)
+		}
+		out = append(out, cj)
+	}
+	return out
+}
+
+// RenderJSON marshals the full controller tree to w.
+func RenderJSON(w io.Writer, controllers []*Controller) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(toControllerJSON(controllers))
+}
+
+// escapeLabel escapes a Prometheus label value.
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return s
+}
+
+// RenderPrometheus writes textfile-collector style metrics for the full
+// controller tree to w.
+func RenderPrometheus(w io.Writer, controllers []*Controller) {
+	fmt.Fprintln(w, "# HELP hpraid_controller_info Static information about a RAID controller.")
+	fmt.Fprintln(w, "# TYPE hpraid_controller_info gauge")
+	fmt.Fprintln(w, "# HELP hpraid_array_unused_bytes Unused space on an array, in bytes.")
+	fmt.Fprintln(w, "# TYPE hpraid_array_unused_bytes gauge")
+	fmt.Fprintln(w, "# HELP hpraid_drive_size_bytes Size of a logical or physical drive, in bytes.")
+	fmt.Fprintln(w, "# TYPE hpraid_drive_size_bytes gauge")
+	fmt.Fprintln(w, "# HELP hpraid_drive_status Drive status: 0=OK, 1=warning, 2=critical.")
+	fmt.Fprintln(w, "# TYPE hpraid_drive_status gauge")
+
+	for _, controller := range controllers {
+		slot := fmt.Sprintf("%d", controller.Slot)
+
+		fmt.Fprintf(w, "hpraid_controller_info{name=\"%s\",serial=\"%s\",type=\"%s\",slot=\"%s\"} 1\n",
+			escapeLabel(controller.Name), escapeLabel(controller.SerialNumber), escapeLabel(controller.Type), slot)
+
+		for ai := range controller.Arrays {
+			array := &controller.Arrays[ai]
+			arrayId := string(array.Id)
+
+			fmt.Fprintf(w, "hpraid_array_unused_bytes{controller=\"%s\",slot=\"%s\",array=\"%s\"} %d\n",
+				escapeLabel(controller.Name), slot, escapeLabel(arrayId), array.UnusedSpace)
+
+			emit := func(drive *Drive, isSpare bool) {
+				driveType := drive.RaidMode
+				if drive.Physical {
+					driveType = drive.Type
+				}
+				role := "active"
+				if isSpare {
+					role = "spare"
+				}
+
+				fmt.Fprintf(w, "hpraid_drive_size_bytes{controller=\"%s\",slot=\"%s\",array=\"%s\",drive=\"%s\",type=\"%s\",role=\"%s\"} %d\n",
+					escapeLabel(controller.Name), slot, escapeLabel(arrayId), escapeLabel(drive.Id), escapeLabel(driveType), role, drive.Size)
+
+				fmt.Fprintf(w, "hpraid_drive_status{controller=\"%s\",slot=\"%s\",array=\"%s\",drive=\"%s\",type=\"%s\",role=\"%s\"} %d\n",
+					escapeLabel(controller.Name), slot, escapeLabel(arrayId), escapeLabel(drive.Id), escapeLabel(driveType), role, driveSeverity(array, drive, isSpare))
+			}
+
+			for di := range array.Drives {
+				emit(&array.Drives[di], false)
+			}
+			for di := range array.Spares {
+				emit(&array.Spares[di], true)
+			}
+		}
+	}
+}